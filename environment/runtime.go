@@ -15,11 +15,26 @@
 package environment
 
 import (
+	"math"
 	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
 
 	"gopkg.in/spacemonkeygo/monkit.v2"
 )
 
+// IncludeMemStats controls whether Runtime continues to report the flat
+// runtime.MemStats fields under "memory.", in addition to whatever
+// RuntimeMetrics already reports from runtime/metrics. It defaults to true
+// so existing dashboards built against the "memory." stats keep working;
+// set it to false once you've migrated to the "runtime_metrics" stats.
+var IncludeMemStats = true
+
+// histogramQuantiles are the percentiles reported for every
+// runtime/metrics Float64Histogram.
+var histogramQuantiles = []float64{0.5, 0.9, 0.99}
+
 // Runtime returns a StatSource that includes information gathered from the
 // Go runtime, including the number of goroutines currently running, and
 // other live memory data. Not expected to be called directly, as this
@@ -28,12 +43,136 @@ func Runtime() monkit.StatSource {
 	return monkit.StatSourceFunc(func(cb func(name string, val float64)) {
 		cb("goroutines", float64(runtime.NumGoroutine()))
 
+		if !IncludeMemStats {
+			return
+		}
 		var stats runtime.MemStats
 		runtime.ReadMemStats(&stats)
 		monkit.Prefix("memory.", monkit.StatSourceFromStruct(stats)).Stats(cb)
 	})
 }
 
+// RuntimeMetrics returns a StatSource built from the runtime/metrics
+// package, which covers a lot of ground runtime.MemStats doesn't: GC pause
+// distributions, scheduler latency, mutex wait time, per-P run queues, and
+// more. Samples are read fresh on every call to Stats. Not expected to be
+// called directly, as this StatSource is added by Register under the
+// "runtime_metrics" key.
+func RuntimeMetrics() monkit.StatSource {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i, d := range descs {
+		samples[i].Name = d.Name
+	}
+
+	return monkit.StatSourceFunc(func(cb func(name string, val float64)) {
+		metrics.Read(samples)
+		for _, s := range samples {
+			name := normalizeMetricName(s.Name)
+			switch s.Value.Kind() {
+			case metrics.KindUint64:
+				cb(name, float64(s.Value.Uint64()))
+			case metrics.KindFloat64:
+				cb(name, s.Value.Float64())
+			case metrics.KindFloat64Histogram:
+				reportHistogram(cb, name, s.Value.Float64Histogram())
+			}
+		}
+	})
+}
+
+// normalizeMetricName turns a runtime/metrics name like
+// "/gc/pauses:seconds" into "runtime.gc.pauses_seconds".
+func normalizeMetricName(name string) string {
+	path, unit, _ := strings.Cut(name, ":")
+	path = strings.TrimPrefix(path, "/")
+	path = strings.ReplaceAll(path, "/", ".")
+	if unit != "" {
+		path += "_" + unit
+	}
+	return "runtime." + path
+}
+
+// reportHistogram summarizes a runtime/metrics histogram the same way
+// monkit's own DurVal distributions are summarized: min/max/avg/count,
+// plus a handful of quantiles estimated by linearly interpolating across
+// the histogram's cumulative bucket counts.
+func reportHistogram(cb func(name string, val float64), name string,
+	h *metrics.Float64Histogram) {
+
+	var count uint64
+	var sum, min, max float64
+	haveMin := false
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		lo, hi := h.Buckets[i], h.Buckets[i+1]
+		// The outermost buckets' bounds are documented to be able to be
+		// -Inf/+Inf; treat samples landing there as if they were at the
+		// opposite, finite bound, since there's no finite value to average
+		// or report as a min/max otherwise.
+		if math.IsInf(lo, -1) {
+			lo = hi
+		}
+		if math.IsInf(hi, 1) {
+			hi = lo
+		}
+		mid := (lo + hi) / 2
+		if !haveMin {
+			min = lo
+			haveMin = true
+		}
+		max = hi
+		count += c
+		sum += mid * float64(c)
+	}
+	cb(name+".count", float64(count))
+	if count == 0 {
+		return
+	}
+	cb(name+".min", min)
+	cb(name+".max", max)
+	cb(name+".avg", sum/float64(count))
+
+	for _, q := range histogramQuantiles {
+		cb(name+"."+quantileSuffix(q), histogramQuantile(h, count, q))
+	}
+}
+
+// histogramQuantile estimates the value at quantile q by walking the
+// histogram's cumulative counts and linearly interpolating within the
+// bucket the quantile falls in.
+func histogramQuantile(h *metrics.Float64Histogram, count uint64,
+	q float64) float64 {
+
+	target := q * float64(count)
+	var cumulative uint64
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		if float64(cumulative+c) >= target {
+			lo, hi := h.Buckets[i], h.Buckets[i+1]
+			if math.IsInf(hi, 1) {
+				return lo
+			}
+			if math.IsInf(lo, -1) {
+				return hi
+			}
+			frac := (target - float64(cumulative)) / float64(c)
+			return lo + frac*(hi-lo)
+		}
+		cumulative += c
+	}
+	return h.Buckets[len(h.Buckets)-1]
+}
+
+func quantileSuffix(q float64) string {
+	return strconv.Itoa(int(q * 100))
+}
+
 func init() {
 	registrations["runtime"] = Runtime()
+	registrations["runtime_metrics"] = RuntimeMetrics()
 }
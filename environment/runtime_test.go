@@ -0,0 +1,121 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package environment
+
+import (
+	"math"
+	"runtime/metrics"
+	"testing"
+)
+
+func TestNormalizeMetricName(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"/gc/pauses:seconds", "runtime.gc.pauses_seconds"},
+		{"/sched/goroutines:goroutines", "runtime.sched.goroutines_goroutines"},
+		{"/memory/classes/heap/free:bytes", "runtime.memory.classes.heap.free_bytes"},
+	} {
+		if got := normalizeMetricName(tc.in); got != tc.want {
+			t.Errorf("normalizeMetricName(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestQuantileSuffix(t *testing.T) {
+	for _, tc := range []struct {
+		q    float64
+		want string
+	}{
+		{0.5, "50"}, {0.9, "90"}, {0.99, "99"},
+	} {
+		if got := quantileSuffix(tc.q); got != tc.want {
+			t.Errorf("quantileSuffix(%v) = %q, want %q", tc.q, got, tc.want)
+		}
+	}
+}
+
+// infHistogram returns a histogram whose outermost bucket is unbounded, as
+// runtime/metrics documents Float64Histogram's first and last buckets can
+// be.
+func infHistogram() *metrics.Float64Histogram {
+	return &metrics.Float64Histogram{
+		Counts:  []uint64{3, 5, 2},
+		Buckets: []float64{0, 1, 2, math.Inf(1)},
+	}
+}
+
+func TestReportHistogramHandlesInfBucket(t *testing.T) {
+	var got []struct {
+		name string
+		val  float64
+	}
+	cb := func(name string, val float64) {
+		got = append(got, struct {
+			name string
+			val  float64
+		}{name, val})
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			t.Errorf("reportHistogram emitted non-finite stat %s = %v", name, val)
+		}
+	}
+	reportHistogram(cb, "runtime.test", infHistogram())
+	if len(got) == 0 {
+		t.Fatal("reportHistogram emitted no stats")
+	}
+}
+
+func TestHistogramQuantileHandlesInfBucket(t *testing.T) {
+	h := infHistogram()
+	val := histogramQuantile(h, 10, 0.99)
+	if math.IsInf(val, 0) || math.IsNaN(val) {
+		t.Errorf("histogramQuantile(0.99) = %v, want a finite value", val)
+	}
+}
+
+// negInfHistogram returns a histogram whose first bucket is unbounded
+// below, the symmetric case to infHistogram's unbounded-above bucket.
+func negInfHistogram() *metrics.Float64Histogram {
+	return &metrics.Float64Histogram{
+		Counts:  []uint64{3, 5, 2},
+		Buckets: []float64{math.Inf(-1), 1, 2, 3},
+	}
+}
+
+func TestReportHistogramHandlesNegInfBucket(t *testing.T) {
+	var got []struct {
+		name string
+		val  float64
+	}
+	cb := func(name string, val float64) {
+		got = append(got, struct {
+			name string
+			val  float64
+		}{name, val})
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			t.Errorf("reportHistogram emitted non-finite stat %s = %v", name, val)
+		}
+	}
+	reportHistogram(cb, "runtime.test", negInfHistogram())
+	if len(got) == 0 {
+		t.Fatal("reportHistogram emitted no stats")
+	}
+}
+
+func TestHistogramQuantileHandlesNegInfBucket(t *testing.T) {
+	h := negInfHistogram()
+	val := histogramQuantile(h, 10, 0.01)
+	if math.IsInf(val, 0) || math.IsNaN(val) {
+		t.Errorf("histogramQuantile(0.01) = %v, want a finite value", val)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"gopkg.in/spacemonkeygo/monkit.v2"
+)
+
+// EncodeSpans converts a set of finished monkit spans into the OTLP span
+// representation, for embedding in either a streamed export or an
+// on-demand dump (see the present package's TraceQueryOTLP).
+//
+// Unlike the live Exporter path, which learns each span's real completion
+// time from its SpanObserver Finish callback, EncodeSpans is only ever
+// handed the already-finished *monkit.Span values themselves, with no
+// per-span timestamp attached. Every span in the batch is therefore
+// stamped with the single time.Now() read here rather than its own actual
+// finish time, so EndTimeUnixNano (and any duration derived from it) in an
+// on-demand /trace/otlp dump is not reliable for comparing spans within
+// the same dump; treat it only as a rough upper bound on when the dump was
+// taken.
+func EncodeSpans(spans []*monkit.Span) []*tracepb.Span {
+	records := make([]spanRecord, 0, len(spans))
+	now := time.Now()
+	for _, s := range spans {
+		records = append(records, spanRecord{span: s, finish: now})
+	}
+	return spansToOTLP(records)
+}
+
+func spansToOTLP(records []spanRecord) []*tracepb.Span {
+	out := make([]*tracepb.Span, 0, len(records))
+	for _, rec := range records {
+		out = append(out, spanToOTLP(rec))
+	}
+	return out
+}
+
+func spanToOTLP(rec spanRecord) *tracepb.Span {
+	s := rec.span
+	span := &tracepb.Span{
+		TraceId:           traceID(s.Trace().Id()),
+		SpanId:            spanID(s.Id()),
+		Name:              s.Func().FullName(),
+		StartTimeUnixNano: uint64(s.Start().UnixNano()),
+		EndTimeUnixNano:   uint64(rec.finish.UnixNano()),
+		Attributes:        spanAttributes(s),
+	}
+	if parent := s.Parent(); parent != nil {
+		span.ParentSpanId = spanID(parent.Id())
+	}
+
+	switch {
+	case rec.panicked:
+		span.Status = &tracepb.Status{
+			Code:    tracepb.Status_STATUS_CODE_ERROR,
+			Message: "panic",
+		}
+	case rec.err != nil:
+		span.Status = &tracepb.Status{
+			Code:    tracepb.Status_STATUS_CODE_ERROR,
+			Message: rec.err.Error(),
+		}
+	default:
+		span.Status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_OK}
+	}
+	return span
+}
+
+func spanAttributes(s *monkit.Span) []*commonpb.KeyValue {
+	var attrs []*commonpb.KeyValue
+	for i, arg := range s.Args() {
+		attrs = append(attrs, stringAttr(argName(i), arg))
+	}
+	for key, val := range s.Annotations() {
+		attrs = append(attrs, stringAttr(key, val))
+	}
+	return attrs
+}
+
+func stringAttr(key string, val interface{}) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key: key,
+		Value: &commonpb.AnyValue{
+			Value: &commonpb.AnyValue_StringValue{
+				StringValue: fmt.Sprint(val),
+			},
+		},
+	}
+}
+
+func argName(i int) string {
+	return "arg" + strconv.Itoa(i)
+}
+
+// traceID turns monkit's 64 bit trace id into a 128 bit OTLP trace id by
+// zero-padding the high 8 bytes and placing the id in the low 8 bytes.
+func traceID(id int64) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[8:], uint64(id))
+	return b
+}
+
+// spanID zero-pads monkit's 64 bit span id out to OTLP's 8 byte span id.
+func spanID(id int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
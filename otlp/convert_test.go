@@ -0,0 +1,42 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTraceID(t *testing.T) {
+	got := traceID(1)
+	want := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("traceID(1) = %x, want %x", got, want)
+	}
+	if len(got) != 16 {
+		t.Errorf("traceID returned %d bytes, want 16", len(got))
+	}
+}
+
+func TestSpanID(t *testing.T) {
+	got := spanID(1)
+	want := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("spanID(1) = %x, want %x", got, want)
+	}
+	if len(got) != 8 {
+		t.Errorf("spanID returned %d bytes, want 8", len(got))
+	}
+}
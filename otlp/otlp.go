@@ -0,0 +1,207 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp streams finished monkit spans out to an OpenTelemetry
+// collector, so that a process instrumented with mon.Task() can be traced
+// by anything that speaks OTLP, without replacing monkit's own tracing.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"gopkg.in/spacemonkeygo/monkit.v2"
+)
+
+const (
+	defaultBufferSize    = 4096
+	defaultFlushInterval = 10 * time.Second
+)
+
+// Config controls how an Exporter talks to its collector.
+type Config struct {
+	// Endpoint is the OTLP/HTTP traces endpoint, e.g.
+	// "https://collector.example.com/v1/traces".
+	Endpoint string
+
+	// Headers are added to every export request, useful for auth.
+	Headers map[string]string
+
+	// BufferSize bounds how many finished spans are held in memory between
+	// flushes. Once full, the oldest buffered span is dropped to make room
+	// for new ones. Defaults to 4096.
+	BufferSize int
+
+	// FlushInterval is how often buffered spans are exported. Defaults to
+	// 10 seconds.
+	FlushInterval time.Duration
+
+	// Client is the http.Client used to deliver export requests. Defaults
+	// to http.DefaultClient.
+	Client *http.Client
+
+	// OnFlushError, if non-nil, is called with the error from any failed
+	// periodic flush to the collector. Flushing is best-effort: a failed
+	// batch is not retried (it's already gone by the time the request to the
+	// collector fails), and Run keeps going on the next tick regardless.
+	// Defaults to a no-op, so transient collector errors are silently
+	// dropped. The final flush Run makes once its context is canceled does
+	// not call OnFlushError; its error is Run's return value instead.
+	OnFlushError func(error)
+}
+
+// Exporter is a monkit.SpanObserver that buffers finished spans and
+// periodically flushes them to an OTLP collector.
+type Exporter struct {
+	cfg Config
+
+	mu   sync.Mutex
+	buf  []spanRecord
+	head int
+}
+
+type spanRecord struct {
+	span     *monkit.Span
+	err      error
+	panicked bool
+	finish   time.Time
+}
+
+// NewExporter creates an Exporter and registers it with reg as a span
+// observer. Call Run to start the periodic flush loop.
+func NewExporter(reg *monkit.Registry, cfg Config) *Exporter {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	e := &Exporter{
+		cfg: cfg,
+		buf: make([]spanRecord, 0, cfg.BufferSize),
+	}
+	reg.ObserveSpans(e)
+	return e
+}
+
+// Start implements monkit.SpanObserver. Spans are only recorded once they
+// finish, so this is a no-op.
+func (e *Exporter) Start(s *monkit.Span) {}
+
+// Finish implements monkit.SpanObserver, buffering the completed span for
+// the next flush.
+func (e *Exporter) Finish(s *monkit.Span, err error, panicked bool,
+	finish time.Time) {
+
+	rec := spanRecord{span: s, err: err, panicked: panicked, finish: finish}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.buf) < cap(e.buf) {
+		e.buf = append(e.buf, rec)
+		return
+	}
+	e.buf[e.head] = rec
+	e.head = (e.head + 1) % len(e.buf)
+}
+
+// Run flushes buffered spans to the collector every cfg.FlushInterval,
+// until ctx is canceled. It is meant to be run in its own goroutine.
+//
+// A failed flush does not stop Run: a collector that's temporarily
+// unreachable (timeout, 503, DNS blip) shouldn't permanently end the only
+// goroutine that ever drains the buffer. The failed batch is reported via
+// cfg.OnFlushError, if set, and otherwise dropped; Run keeps flushing on
+// the next tick.
+func (e *Exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return e.flush(context.Background())
+		case <-ticker.C:
+			if err := e.flush(ctx); err != nil && e.cfg.OnFlushError != nil {
+				e.cfg.OnFlushError(err)
+			}
+		}
+	}
+}
+
+// flush drains the buffer and ships it to the collector as a single OTLP
+// ExportTraceServiceRequest.
+func (e *Exporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	records := e.buf
+	e.buf = make([]spanRecord, 0, cap(records))
+	e.head = 0
+	e.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+	return e.export(ctx, records)
+}
+
+func (e *Exporter) export(ctx context.Context, records []spanRecord) error {
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{
+			{
+				ScopeSpans: []*tracepb.ScopeSpans{
+					{Spans: spansToOTLP(records)},
+				},
+			},
+		},
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range e.cfg.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := e.cfg.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("otlp: export to %s failed: %s: %s",
+			e.cfg.Endpoint, resp.Status, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package present
+
+import (
+	"io"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"gopkg.in/spacemonkeygo/monkit.v2"
+	"gopkg.in/spacemonkeygo/monkit.v2/otlp"
+)
+
+// TraceQueryOTLP watches for a single matching trace, the same way
+// TraceQuerySVG and TraceQueryJSON do, then writes it to w as a serialized
+// OTLP ExportTraceServiceRequest protobuf.
+func TraceQueryOTLP(reg *monkit.Registry, w io.Writer,
+	matcher func(s *monkit.Span) bool) (err error) {
+
+	spans, err := collectMatchingSpans(reg, matcher)
+	if err != nil {
+		return err
+	}
+
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{
+			{
+				ScopeSpans: []*tracepb.ScopeSpans{
+					{Spans: otlp.EncodeSpans(spans)},
+				},
+			},
+		},
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
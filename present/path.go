@@ -55,10 +55,12 @@ func curry(reg *monkit.Registry,
 //  * /funcs/json         - returns the result of FuncsJSON
 //  * /stats, /stats/text - returns the result of StatsText
 //  * /stats/json         - returns the result of StatsJSON
+//  * /stats/prometheus   - returns the result of StatsPrometheus
 //  * /trace/svg          - returns the result of TraceQuerySVG
 //  * /trace/json         - returns the result of TraceQueryJSON
+//  * /trace/otlp         - returns the result of TraceQueryOTLP
 //
-// The last two paths are worth discussing in more detail, as they take
+// The last three paths are worth discussing in more detail, as they take
 // query parameters. All trace endpoints require at least one of the following
 // two query parameters:
 //  * regex    - If provided, the very next Span that crosses a Func that has
@@ -129,6 +131,10 @@ func FromRequest(reg *monkit.Registry, path string, query url.Values) (
 			return func(w io.Writer) error {
 				return FilteredStatsJSON(reg, w, prefix)
 			}, "application/json; charset=utf-8", nil
+		case "prometheus":
+			return func(w io.Writer) error {
+				return FilteredStatsPrometheus(reg, w, prefix)
+			}, "text/plain; version=0.0.4", nil
 		}
 
 	case "trace":
@@ -195,6 +201,10 @@ func FromRequest(reg *monkit.Registry, path string, query url.Values) (
 			return func(w io.Writer) error {
 				return TraceQueryJSON(reg, w, spanMatcher)
 			}, "application/json; charset=utf-8", nil
+		case "otlp":
+			return func(w io.Writer) error {
+				return TraceQueryOTLP(reg, w, spanMatcher)
+			}, "application/x-protobuf", nil
 		}
 	}
 	return nil, "", NotFound.New("path not found: %s", path)
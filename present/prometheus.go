@@ -0,0 +1,279 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package present
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/spacemonkeygo/monkit.v2"
+)
+
+// distSuffixes are the field names monkit's IntVal/FloatVal distributions
+// hang off of a base stat name, e.g. "request_size.avg".
+var distSuffixes = map[string]bool{
+	"avg": true, "count": true, "min": true,
+	"max": true, "recent": true, "sum": true,
+}
+
+// quantileSuffix matches the percentile fields DurVal reservoirs emit, e.g.
+// "request_latency.50", "request_latency.99".
+var quantileSuffix = regexp.MustCompile(`^\d+$`)
+
+// promSeries accumulates every field observed for a single base stat name
+// plus label set, so that StatsPrometheus can decide how to present the
+// family once all of a stat's fields have been seen.
+type promSeries struct {
+	labels string // pre-formatted, e.g. `{scope="bar"}`, or "" if none
+	fields map[string]float64
+}
+
+// StatsPrometheus writes all of reg's stats to w in the Prometheus text
+// exposition format (version 0.0.4), suitable for serving from a
+// "/metrics"-style scrape endpoint.
+func StatsPrometheus(reg *monkit.Registry, w io.Writer) error {
+	return FilteredStatsPrometheus(reg, w, "")
+}
+
+// FilteredStatsPrometheus is like StatsPrometheus, but only stats whose name
+// begins with prefix are included.
+func FilteredStatsPrometheus(reg *monkit.Registry, w io.Writer, prefix string) (
+	err error) {
+
+	families := map[string]map[string]*promSeries{}
+	var order []string
+
+	reg.Stats(func(name string, val float64) {
+		if !strings.HasPrefix(name, prefix) {
+			return
+		}
+		// monkit appends a distribution's field suffix (".avg", ".count",
+		// a quantile, ...) to whatever name it was given, tags and all —
+		// e.g. "foo,scope=bar.avg", not "foo.avg,scope=bar" — so the
+		// suffix has to come off the raw name first; only then is what's
+		// left ("foo,scope=bar") a name splitLabels can parse.
+		base, suffix := splitSuffix(name)
+		base, labels := splitLabels(base)
+		// Group by the raw base name, not its sanitized form: two distinct
+		// monkit stats that only differ by separator (e.g. "a/b" vs "a.b")
+		// must not collapse into the same family and clobber each other's
+		// fields.
+		byLabels, ok := families[base]
+		if !ok {
+			byLabels = map[string]*promSeries{}
+			families[base] = byLabels
+			order = append(order, base)
+		}
+		series, ok := byLabels[labels]
+		if !ok {
+			series = &promSeries{labels: labels, fields: map[string]float64{}}
+			byLabels[labels] = series
+		}
+		series.fields[suffix] = val
+	})
+
+	sort.Strings(order)
+	for _, base := range order {
+		if err := writeFamily(w, base, families[base]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFamily renders every label-set collected for a single base stat
+// name. base is the raw, unsanitized monkit stat name; it's sanitized here,
+// at the point metric names are actually emitted.
+func writeFamily(w io.Writer, base string, byLabels map[string]*promSeries) (
+	err error) {
+
+	name := sanitize(base)
+
+	var labelKeys []string
+	for labels := range byLabels {
+		labelKeys = append(labelKeys, labels)
+	}
+	sort.Strings(labelKeys)
+
+	if isTimer(byLabels) {
+		metric := name + "_seconds"
+		if _, err = fmt.Fprintf(w, "# TYPE %s summary\n", metric); err != nil {
+			return err
+		}
+		for _, labels := range labelKeys {
+			series := byLabels[labels]
+			for _, suffix := range sortedKeys(series.fields) {
+				val := series.fields[suffix]
+				switch {
+				case suffix == "count":
+					err = writeSample(w, metric+"_count", labels, "", val)
+				case suffix == "sum":
+					err = writeSample(w, metric+"_sum", labels, "", val)
+				case quantileSuffix.MatchString(suffix):
+					q, _ := strconv.ParseFloat(suffix, 64)
+					err = writeSample(w, metric, labels,
+						fmt.Sprintf(`quantile="%s"`, strconv.FormatFloat(q/100, 'f', -1, 64)), val)
+				default:
+					err = writeSample(w, metric+"_"+suffix, labels, "", val)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// Group by the metric name actually emitted (base name plus suffix)
+	// before writing anything: a labeled stat like "foo,scope=a" and
+	// "foo,scope=b" both resolve to the same metric "monkit_foo" and must
+	// share a single "# TYPE" line, not one per label set.
+	type sample struct {
+		labels string
+		val    float64
+	}
+	metricOrder := []string{}
+	metricSamples := map[string][]sample{}
+	metricType := map[string]string{}
+	for _, labels := range labelKeys {
+		series := byLabels[labels]
+		for _, suffix := range sortedKeys(series.fields) {
+			val := series.fields[suffix]
+			metric := name
+			if suffix != "" {
+				metric = name + "_" + suffix
+			}
+			if _, ok := metricSamples[metric]; !ok {
+				metricOrder = append(metricOrder, metric)
+				// The ".count" field of an IntVal/FloatVal distribution is
+				// monotonic by construction, so it's always a Prometheus
+				// counter regardless of what the underlying stat represents.
+				typ := "gauge"
+				if suffix == "count" {
+					typ = "counter"
+				}
+				metricType[metric] = typ
+			}
+			metricSamples[metric] = append(metricSamples[metric], sample{labels: labels, val: val})
+		}
+	}
+
+	for _, metric := range metricOrder {
+		if _, err = fmt.Fprintf(w, "# TYPE %s %s\n", metric, metricType[metric]); err != nil {
+			return err
+		}
+		for _, s := range metricSamples[metric] {
+			if err = writeSample(w, metric, s.labels, "", s.val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTimer reports whether the fields collected for a stat name look like a
+// DurVal reservoir, i.e. whether any label set has at least one percentile
+// field. Other distribution fields are not required.
+func isTimer(byLabels map[string]*promSeries) bool {
+	for _, series := range byLabels {
+		for suffix := range series.fields {
+			if quantileSuffix.MatchString(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeSample writes a single Prometheus sample line, merging labels (from
+// the stat's keyed segments) with extra (e.g. a quantile label), if any.
+func writeSample(w io.Writer, metric, labels, extra string, val float64) error {
+	switch {
+	case labels == "" && extra == "":
+		_, err := fmt.Fprintf(w, "%s %s\n", metric, formatFloat(val))
+		return err
+	case labels == "":
+		_, err := fmt.Fprintf(w, "%s{%s} %s\n", metric, extra, formatFloat(val))
+		return err
+	case extra == "":
+		_, err := fmt.Fprintf(w, "%s%s %s\n", metric, labels, formatFloat(val))
+		return err
+	default:
+		// labels is already wrapped in braces; splice extra in before the
+		// closing brace.
+		_, err := fmt.Fprintf(w, "%s,%s} %s\n",
+			strings.TrimSuffix(labels, "}"), extra, formatFloat(val))
+		return err
+	}
+}
+
+func formatFloat(val float64) string {
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+// splitLabels pulls the keyed segments monkit appends to a stat name (e.g.
+// "foo,scope=bar") off into a Prometheus label list (e.g. `{scope="bar"}`).
+func splitLabels(name string) (base, labels string) {
+	idx := strings.Index(name, ",")
+	if idx == -1 {
+		return name, ""
+	}
+	base = name[:idx]
+	var pairs []string
+	for _, pair := range strings.Split(name[idx+1:], ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, sanitize(kv[0]), kv[1]))
+	}
+	if len(pairs) == 0 {
+		return base, ""
+	}
+	return base, "{" + strings.Join(pairs, ",") + "}"
+}
+
+// splitSuffix separates the trailing distribution/quantile field (if any)
+// monkit's IntVal/FloatVal/DurVal stats hang off a base name.
+func splitSuffix(name string) (base, suffix string) {
+	idx := strings.LastIndex(name, ".")
+	if idx == -1 {
+		return name, ""
+	}
+	candidate := name[idx+1:]
+	if distSuffixes[candidate] || quantileSuffix.MatchString(candidate) {
+		return name[:idx], candidate
+	}
+	return name, ""
+}
+
+// sanitize turns a monkit stat name into a valid Prometheus metric name.
+func sanitize(name string) string {
+	name = strings.NewReplacer(".", "_", "/", "_", "-", "_").Replace(name)
+	return "monkit_" + name
+}
+
+func sortedKeys(fields map[string]float64) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,204 @@
+// Copyright (C) 2021 Space Monkey, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package present
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/spacemonkeygo/monkit.v2"
+)
+
+func TestSanitize(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"foo", "monkit_foo"},
+		{"foo.bar", "monkit_foo_bar"},
+		{"foo/bar", "monkit_foo_bar"},
+		{"foo-bar", "monkit_foo_bar"},
+	} {
+		if got := sanitize(tc.in); got != tc.want {
+			t.Errorf("sanitize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// TestSanitizeCollisionDoesNotMergeBaseNames guards against regressing to
+// using the sanitized name as a family's grouping key: two stats that only
+// differ by separator style sanitize to the same Prometheus name, but must
+// remain distinct families until the point of emission.
+func TestSanitizeCollisionDoesNotMergeBaseNames(t *testing.T) {
+	a, b := "foo/bar", "foo.bar"
+	if a == b {
+		t.Fatal("test fixture is not actually distinct")
+	}
+	if sanitize(a) != sanitize(b) {
+		t.Fatal("test fixture does not actually collide once sanitized")
+	}
+
+	reg := monkit.NewRegistry()
+	reg.Chain(monkit.StatSourceFunc(func(cb func(name string, val float64)) {
+		cb(a, 1)
+		cb(b, 2)
+	}))
+
+	var buf bytes.Buffer
+	if err := FilteredStatsPrometheus(reg, &buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{"monkit_foo_bar 1", "monkit_foo_bar 2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q; got:\n%s", want, out)
+		}
+	}
+}
+
+func TestSplitLabels(t *testing.T) {
+	for _, tc := range []struct{ in, base, labels string }{
+		{"foo", "foo", ""},
+		{"foo,scope=bar", "foo", `{scope="bar"}`},
+		{"foo,scope=bar,shard=1", "foo", `{scope="bar",shard="1"}`},
+	} {
+		base, labels := splitLabels(tc.in)
+		if base != tc.base || labels != tc.labels {
+			t.Errorf("splitLabels(%q) = (%q, %q), want (%q, %q)",
+				tc.in, base, labels, tc.base, tc.labels)
+		}
+	}
+}
+
+// TestFilteredStatsPrometheusRendersFamilies exercises FilteredStatsPrometheus,
+// writeFamily, and isTimer end-to-end against a registry chaining a single
+// StatSource with a counter, a gauge, and a timer-shaped family.
+func TestFilteredStatsPrometheusRendersFamilies(t *testing.T) {
+	reg := monkit.NewRegistry()
+	reg.Chain(monkit.StatSourceFunc(func(cb func(name string, val float64)) {
+		cb("requests.count", 42)
+		cb("goroutines", 7)
+		cb("latency.50", 0.1)
+		cb("latency.99", 0.5)
+		cb("latency.count", 10)
+		cb("latency.sum", 1.2)
+	}))
+
+	var buf bytes.Buffer
+	if err := FilteredStatsPrometheus(reg, &buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE monkit_requests_count counter",
+		"monkit_requests_count 42",
+		"# TYPE monkit_goroutines gauge",
+		"monkit_goroutines 7",
+		"# TYPE monkit_latency_seconds summary",
+		`monkit_latency_seconds{quantile="0.5"} 0.1`,
+		`monkit_latency_seconds{quantile="0.99"} 0.5`,
+		"monkit_latency_seconds_count 10",
+		"monkit_latency_seconds_sum 1.2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q; got:\n%s", want, out)
+		}
+	}
+}
+
+// TestFilteredStatsPrometheusSingleTypeLinePerLabeledFamily guards against
+// regressing to emitting a "# TYPE" line per label set: a labeled stat with
+// more than one label combination must still produce exactly one "# TYPE"
+// line for the metric, or Prometheus's text format parser rejects the whole
+// payload with "second TYPE line for metric name".
+func TestFilteredStatsPrometheusSingleTypeLinePerLabeledFamily(t *testing.T) {
+	reg := monkit.NewRegistry()
+	reg.Chain(monkit.StatSourceFunc(func(cb func(name string, val float64)) {
+		cb("foo,scope=a", 1)
+		cb("foo,scope=b", 2)
+	}))
+
+	var buf bytes.Buffer
+	if err := FilteredStatsPrometheus(reg, &buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if n := strings.Count(out, "# TYPE monkit_foo "); n != 1 {
+		t.Errorf("expected exactly one \"# TYPE monkit_foo\" line, got %d; output:\n%s", n, out)
+	}
+	for _, want := range []string{
+		`monkit_foo{scope="a"} 1`,
+		`monkit_foo{scope="b"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q; got:\n%s", want, out)
+		}
+	}
+}
+
+// TestFilteredStatsPrometheusRendersTaggedDistribution exercises the case
+// where a stat is both keyed (tags) and a distribution (suffixed fields):
+// monkit bakes the tags into the name before appending the suffix, e.g.
+// "request_size,scope=bar.avg", so the suffix must be split off before the
+// tags are, or every field of the distribution collapses into a bogus
+// per-field label value instead of one family with one label set.
+func TestFilteredStatsPrometheusRendersTaggedDistribution(t *testing.T) {
+	reg := monkit.NewRegistry()
+	reg.Chain(monkit.StatSourceFunc(func(cb func(name string, val float64)) {
+		cb("request_size,scope=bar.avg", 3)
+		cb("request_size,scope=bar.count", 10)
+		cb("request_size,scope=bar.min", 1)
+		cb("request_size,scope=bar.max", 5)
+	}))
+
+	var buf bytes.Buffer
+	if err := FilteredStatsPrometheus(reg, &buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if strings.Contains(out, "avg\"") || strings.Contains(out, "count\"") {
+		t.Fatalf("distribution suffix leaked into a label value; got:\n%s", out)
+	}
+	for _, want := range []string{
+		"# TYPE monkit_request_size_count counter",
+		`monkit_request_size_count{scope="bar"} 10`,
+		"# TYPE monkit_request_size_avg gauge",
+		`monkit_request_size_avg{scope="bar"} 3`,
+		`monkit_request_size_min{scope="bar"} 1`,
+		`monkit_request_size_max{scope="bar"} 5`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q; got:\n%s", want, out)
+		}
+	}
+}
+
+func TestSplitSuffix(t *testing.T) {
+	for _, tc := range []struct{ in, base, suffix string }{
+		{"foo", "foo", ""},
+		{"foo.avg", "foo", "avg"},
+		{"foo.count", "foo", "count"},
+		{"foo.99", "foo", "99"},
+		{"foo.bar", "foo.bar", ""},
+	} {
+		base, suffix := splitSuffix(tc.in)
+		if base != tc.base || suffix != tc.suffix {
+			t.Errorf("splitSuffix(%q) = (%q, %q), want (%q, %q)",
+				tc.in, base, suffix, tc.base, tc.suffix)
+		}
+	}
+}